@@ -0,0 +1,95 @@
+// Package backend defines the storage interface dscli's commands upload, download, remove,
+// and list files through, so Discord-specific logic lives behind one implementation instead
+// of being hardcoded into every command.
+package backend
+
+import (
+	"fmt"
+	"io"
+)
+
+// FileInfo describes one file a Backend currently holds.
+type FileInfo struct {
+	Name string
+	Size int64
+}
+
+// ChunkWriter accepts a file's chunks in order and finalizes storage once every chunk has
+// been written.
+type ChunkWriter interface {
+	// ChunkSize reports the byte size callers should split the local file into before
+	// calling WriteChunk; the last chunk may be shorter.
+	ChunkSize() int64
+	// WriteChunk uploads the chunk at the given 1-based index. A writer backing a resumed
+	// file may silently skip chunks it already has.
+	WriteChunk(index int, data io.Reader) error
+	// Close finalizes the file and releases any resources held by the writer.
+	Close() error
+}
+
+// ChunkReader reads a stored file back out in the same chunks it was written in.
+type ChunkReader interface {
+	// NumChunks reports how many chunks the stored file has.
+	NumChunks() int
+	// OpenChunk opens the chunk at the given 1-based index for reading.
+	OpenChunk(index int) (io.ReadCloser, error)
+	// ExpectedSHA256 returns the digest a download should match once every chunk is in, if
+	// the backend recorded one at upload time; it need not be a hash of the file's raw
+	// bytes (see the Discord backend's chunk manifest), so callers must use a backend's own
+	// hashing scheme rather than assuming one. ok is false when no such digest is
+	// available, e.g. the local backend or a Discord channel that predates the chunk
+	// manifest.
+	ExpectedSHA256() (sum string, ok bool)
+	Close() error
+}
+
+// Backend is a storage provider dscli's commands work against instead of talking to
+// Discord directly.
+type Backend interface {
+	// CreateFile reserves storage for a new file of the given size and returns a writer
+	// for its chunks. It errors if name already exists.
+	CreateFile(name string, size int64) (ChunkWriter, error)
+	// OpenWriter resumes an existing file, returning a writer that may skip chunks already
+	// stored (the local backend's writer rewrites every chunk).
+	OpenWriter(name string, size int64) (ChunkWriter, error)
+	// OpenReader opens a previously stored file for chunked reading.
+	OpenReader(name string) (ChunkReader, error)
+	// Delete removes a stored file.
+	Delete(name string) error
+	// List returns every file currently stored.
+	List() ([]FileInfo, error)
+	// Close releases any resources (connections, sessions) the backend holds.
+	Close() error
+}
+
+// Options configures how a Backend uploads chunked files. Backends that don't support a
+// given knob (e.g. a local filesystem backend has no rate limit) silently ignore it, except
+// Encrypt, which the local backend rejects with an error.
+type Options struct {
+	Parallel  int
+	RateLimit int64
+	Burst     int
+	Encrypt   bool
+	Password  string
+	Debug     bool
+}
+
+// Factory constructs a Backend from Options.
+type Factory func(Options) (Backend, error)
+
+var factories = map[string]Factory{}
+
+// Register makes a backend available under name for New and --backend to select. Backend
+// packages call this from their init().
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// New constructs the named backend, e.g. "discord" or "local".
+func New(name string, opts Options) (Backend, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+	return factory(opts)
+}
@@ -0,0 +1,183 @@
+package discord
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// topicVersionPrefix marks the new topic format used once encryption support exists;
+// channels without it carry the legacy plain-size topic and are read as unencrypted.
+const topicVersionPrefix = "v2:"
+
+// argon2Params is baked into every v2 topic so a different default in a later release
+// doesn't break deriving the key for files uploaded under an older one.
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+var defaultArgon2Params = argon2Params{time: 3, memory: 64 * 1024, threads: 4}
+
+func (p argon2Params) String() string {
+	return fmt.Sprintf("%d,%d,%d", p.time, p.memory, p.threads)
+}
+
+func parseArgon2Params(s string) (argon2Params, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return argon2Params{}, fmt.Errorf("malformed kdf params %q", s)
+	}
+
+	time, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return argon2Params{}, err
+	}
+	memory, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return argon2Params{}, err
+	}
+	threads, err := strconv.ParseUint(parts[2], 10, 8)
+	if err != nil {
+		return argon2Params{}, err
+	}
+
+	return argon2Params{time: uint32(time), memory: uint32(memory), threads: uint8(threads)}, nil
+}
+
+// topicInfo is a channel topic decoded into its file size and, for encrypted files, the
+// salt and KDF parameters needed to re-derive the key from a passphrase.
+type topicInfo struct {
+	size      int64
+	encrypted bool
+	salt      []byte
+	kdf       argon2Params
+}
+
+// formatTopic renders the channel topic for a file of the given size. A nil salt keeps the
+// legacy plain-size format; otherwise the topic is tagged "v2" with the salt and KDF
+// parameters a future download will need to re-derive the key.
+func formatTopic(size int64, salt []byte, kdf argon2Params) string {
+	if salt == nil {
+		return strconv.FormatInt(size, 10)
+	}
+	return fmt.Sprintf("%s%d:%s:%s", topicVersionPrefix, size, hex.EncodeToString(salt), kdf)
+}
+
+// parseTopic decodes a channel topic, falling back to the legacy plain-size format for
+// channels created before encryption support existed.
+func parseTopic(topic string) (topicInfo, error) {
+	if !strings.HasPrefix(topic, topicVersionPrefix) {
+		size, err := strconv.ParseInt(topic, 10, 64)
+		if err != nil {
+			return topicInfo{}, fmt.Errorf("malformed topic %q", topic)
+		}
+		return topicInfo{size: size}, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(topic, topicVersionPrefix), ":", 3)
+	if len(parts) != 3 {
+		return topicInfo{}, fmt.Errorf("malformed v2 topic %q", topic)
+	}
+
+	size, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return topicInfo{}, err
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return topicInfo{}, err
+	}
+	kdf, err := parseArgon2Params(parts[2])
+	if err != nil {
+		return topicInfo{}, err
+	}
+
+	return topicInfo{size: size, encrypted: true, salt: salt, kdf: kdf}, nil
+}
+
+// resolvePassword returns the passphrase for --encrypt/--decrypt, preferring the flag over
+// the DSCLI_PASSWORD environment variable.
+func resolvePassword(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if env := os.Getenv("DSCLI_PASSWORD"); env != "" {
+		return env, nil
+	}
+	return "", errors.New("encryption requires --password or DSCLI_PASSWORD")
+}
+
+// deriveKey turns a passphrase and salt into a 32-byte AES-256 key via Argon2id.
+func deriveKey(password string, salt []byte, kdf argon2Params) []byte {
+	return argon2.IDKey([]byte(password), salt, kdf.time, kdf.memory, kdf.threads, 32)
+}
+
+// newChunkAEAD builds the AES-256-GCM cipher chunks are sealed/opened with.
+func newChunkAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newEncryptionForUpload generates a fresh random salt and derives the AEAD a new upload
+// should encrypt its chunks with.
+func newEncryptionForUpload(password string) ([]byte, cipher.AEAD, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+
+	resolved, err := resolvePassword(password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := newChunkAEAD(deriveKey(resolved, salt, defaultArgon2Params))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return salt, aead, nil
+}
+
+// chunkNonce derives a unique AEAD nonce for a block from the file's salt, so a given key
+// never reuses a nonce without having to store one per chunk.
+func chunkNonce(salt []byte, blockNumber int) []byte {
+	var blockBytes [8]byte
+	binary.BigEndian.PutUint64(blockBytes[:], uint64(blockNumber))
+
+	sum := sha256.New()
+	sum.Write(salt)
+	sum.Write(blockBytes[:])
+
+	return sum.Sum(nil)[:12]
+}
+
+// encryptChunk seals plaintext for the given block number.
+func encryptChunk(aead cipher.AEAD, salt []byte, blockNumber int, plaintext []byte) []byte {
+	return aead.Seal(nil, chunkNonce(salt, blockNumber), plaintext, nil)
+}
+
+// decryptChunk opens ciphertext for the given block number, returning an error if
+// authentication fails so a corrupted or tampered chunk aborts the transfer.
+func decryptChunk(aead cipher.AEAD, salt []byte, blockNumber int, ciphertext []byte) ([]byte, error) {
+	plaintext, err := aead.Open(nil, chunkNonce(salt, blockNumber), ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d failed authentication: %v", blockNumber, err)
+	}
+	return plaintext, nil
+}
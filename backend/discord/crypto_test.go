@@ -0,0 +1,93 @@
+package discord
+
+import "testing"
+
+func TestFormatParseTopicLegacy(t *testing.T) {
+	topic := formatTopic(1234, nil, argon2Params{})
+
+	info, err := parseTopic(topic)
+	if err != nil {
+		t.Fatalf("parseTopic: %v", err)
+	}
+	if info.encrypted {
+		t.Fatal("parseTopic: legacy topic should not be marked encrypted")
+	}
+	if info.size != 1234 {
+		t.Fatalf("size = %d, want 1234", info.size)
+	}
+}
+
+func TestFormatParseTopicV2RoundTrip(t *testing.T) {
+	salt := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	kdf := defaultArgon2Params
+
+	topic := formatTopic(5678, salt, kdf)
+
+	info, err := parseTopic(topic)
+	if err != nil {
+		t.Fatalf("parseTopic: %v", err)
+	}
+	if !info.encrypted {
+		t.Fatal("parseTopic: v2 topic should be marked encrypted")
+	}
+	if info.size != 5678 {
+		t.Fatalf("size = %d, want 5678", info.size)
+	}
+	if string(info.salt) != string(salt) {
+		t.Fatalf("salt = %v, want %v", info.salt, salt)
+	}
+	if info.kdf != kdf {
+		t.Fatalf("kdf = %+v, want %+v", info.kdf, kdf)
+	}
+}
+
+func TestParseTopicMalformed(t *testing.T) {
+	if _, err := parseTopic("not-a-number"); err == nil {
+		t.Fatal("parseTopic: expected error for a malformed legacy topic")
+	}
+	if _, err := parseTopic("v2:1234:zz:3,4,5"); err == nil {
+		t.Fatal("parseTopic: expected error for a v2 topic with a bad salt")
+	}
+	if _, err := parseTopic("v2:1234:abcd"); err == nil {
+		t.Fatal("parseTopic: expected error for a v2 topic missing fields")
+	}
+}
+
+func TestEncryptDecryptChunkRoundTrip(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	aead, err := newChunkAEAD(deriveKey("correct horse", salt, defaultArgon2Params))
+	if err != nil {
+		t.Fatalf("newChunkAEAD: %v", err)
+	}
+
+	plaintext := []byte("the quick brown fox jumps over the lazy dog")
+	ciphertext := encryptChunk(aead, salt, 3, plaintext)
+
+	got, err := decryptChunk(aead, salt, 3, ciphertext)
+	if err != nil {
+		t.Fatalf("decryptChunk: %v", err)
+	}
+	if string(got) != string(plaintext) {
+		t.Fatalf("decryptChunk = %q, want %q", got, plaintext)
+	}
+}
+
+func TestDecryptChunkWrongBlockNumberFails(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	aead, err := newChunkAEAD(deriveKey("correct horse", salt, defaultArgon2Params))
+	if err != nil {
+		t.Fatalf("newChunkAEAD: %v", err)
+	}
+
+	ciphertext := encryptChunk(aead, salt, 1, []byte("hello"))
+	if _, err := decryptChunk(aead, salt, 2, ciphertext); err == nil {
+		t.Fatal("decryptChunk: expected authentication failure for the wrong block number")
+	}
+}
+
+func TestChunkNonceDiffersPerBlock(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	if string(chunkNonce(salt, 1)) == string(chunkNonce(salt, 2)) {
+		t.Fatal("chunkNonce: different block numbers produced the same nonce")
+	}
+}
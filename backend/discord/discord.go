@@ -0,0 +1,227 @@
+// Package discord implements backend.Backend on top of Discord channels: one channel per
+// file, one message per chunk, named by the chunk's 1-based index.
+package discord
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/darenliang/dscli/backend"
+	"github.com/darenliang/dscli/common"
+)
+
+func init() {
+	backend.Register("discord", New)
+}
+
+// Backend stores each file as a Discord text channel, chunked into messages small enough
+// to fit the guild's upload limit.
+type Backend struct {
+	session *discordgo.Session
+	guild   *discordgo.Guild
+	fileMap map[string]*discordgo.Channel
+	maxSize int64
+	opts    backend.Options
+}
+
+// New opens a Discord session and resolves the guild's file channels, as every up/rm/ls
+// invocation used to do directly.
+func New(opts backend.Options) (backend.Backend, error) {
+	session, guild, channels, err := common.GetDiscordSession()
+	if err != nil {
+		return nil, err
+	}
+
+	fileMap, err := common.ParseFileMap(channels)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	maxSize, err := common.GetMaxFileSizeUpload(session, guild)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	return &Backend{
+		session: session,
+		guild:   guild,
+		fileMap: fileMap,
+		maxSize: int64(maxSize),
+		opts:    opts,
+	}, nil
+}
+
+func (b *Backend) Close() error {
+	return b.session.Close()
+}
+
+func (b *Backend) List() ([]backend.FileInfo, error) {
+	files := make([]backend.FileInfo, 0, len(b.fileMap))
+	for name, channel := range b.fileMap {
+		info, err := parseTopic(channel.Topic)
+		if err != nil {
+			continue
+		}
+		files = append(files, backend.FileInfo{Name: name, Size: info.size})
+	}
+	return files, nil
+}
+
+func (b *Backend) Delete(name string) error {
+	channel, ok := b.fileMap[name]
+	if !ok {
+		return fmt.Errorf("%s not found", name)
+	}
+
+	if _, err := b.session.ChannelDelete(channel.ID); err != nil {
+		return fmt.Errorf("cannot delete file: %v", err)
+	}
+
+	delete(b.fileMap, name)
+	return nil
+}
+
+// CreateFile creates a new channel for name and returns a writer that uploads every chunk,
+// posting and pinning the chunk manifest once Close is called.
+func (b *Backend) CreateFile(name string, size int64) (backend.ChunkWriter, error) {
+	if _, ok := b.fileMap[name]; ok {
+		return nil, fmt.Errorf("%s already exists on Discord", name)
+	}
+	if len(b.fileMap) >= common.MaxDiscordChannels {
+		return nil, fmt.Errorf("max Discord channel limit of %d is reached", common.MaxDiscordChannels)
+	}
+
+	encodedName, err := common.EncodeFilename(name)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := b.session.GuildChannelCreate(b.guild.ID, encodedName, discordgo.ChannelTypeGuildText)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create remote file: %v", err)
+	}
+
+	w := newChunkWriter(b, channel, name, size, b.maxSize-50)
+	w.fresh = true
+
+	if b.opts.Encrypt {
+		salt, aead, err := newEncryptionForUpload(b.opts.Password)
+		if err != nil {
+			return nil, err
+		}
+		w.salt, w.aead = salt, aead
+	}
+
+	topic := formatTopic(size, w.salt, defaultArgon2Params)
+	// ignore if errored since it is not critical
+	_, _ = b.session.ChannelEdit(channel.ID, &discordgo.ChannelEdit{Topic: topic})
+
+	b.fileMap[name] = channel
+	return w, nil
+}
+
+// OpenWriter resumes name, skipping chunks already present. Channels with a pinned
+// manifest are resumed by comparing it against what's actually on Discord; channels
+// without one fall back to dscli's original inferred-block-size resume.
+func (b *Backend) OpenWriter(name string, size int64) (backend.ChunkWriter, error) {
+	channel, ok := b.fileMap[name]
+	if !ok {
+		return nil, fmt.Errorf("%s does not exist on Discord", name)
+	}
+
+	info, err := parseTopic(channel.Topic)
+	if err != nil {
+		return nil, err
+	}
+	if info.size != size {
+		return nil, errors.New("remote file size does not match local file size")
+	}
+
+	manifest, err := fetchManifest(b.session, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	var w *chunkWriter
+	if manifest != nil {
+		present, err := presentChunks(b.session, channel)
+		if err != nil {
+			return nil, err
+		}
+		if len(missingChunks(manifest, present)) == 0 {
+			return nil, errors.New("upload is already complete")
+		}
+
+		w = newChunkWriter(b, channel, name, size, manifest.ChunkSize)
+		w.present = present
+	} else {
+		chunkSize, doneBlocks, err := inferLegacyProgress(b.session, channel, size, b.maxSize)
+		if err != nil {
+			return nil, err
+		}
+
+		w = newChunkWriter(b, channel, name, size, chunkSize)
+		w.skipBelow = doneBlocks
+	}
+
+	if info.encrypted {
+		password, err := resolvePassword(b.opts.Password)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := newChunkAEAD(deriveKey(password, info.salt, info.kdf))
+		if err != nil {
+			return nil, err
+		}
+		w.salt, w.aead = info.salt, aead
+	}
+
+	return w, nil
+}
+
+// OpenReader opens name for chunked reading, transparently decrypting chunks if the topic
+// marks the file as encrypted and, for manifest-aware channels, verifying each chunk (and
+// ultimately the whole file) against the digests recorded at upload time.
+func (b *Backend) OpenReader(name string) (backend.ChunkReader, error) {
+	channel, ok := b.fileMap[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+
+	info, err := parseTopic(channel.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := chunkURLs(b.session, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := fetchManifest(b.session, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &chunkReader{urls: urls, numChunks: len(urls), manifest: manifest}
+	if manifest != nil {
+		r.chunksByIndex = indexChunks(manifest)
+	}
+
+	if info.encrypted {
+		password, err := resolvePassword(b.opts.Password)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := newChunkAEAD(deriveKey(password, info.salt, info.kdf))
+		if err != nil {
+			return nil, err
+		}
+		r.aead, r.salt = aead, info.salt
+	}
+
+	return r, nil
+}
@@ -0,0 +1,283 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// manifestFilename is the attachment name that marks a channel as manifest-aware.
+const manifestFilename = "manifest.json"
+
+// ChunkInfo describes one block of a chunked file as recorded in its Manifest.
+type ChunkInfo struct {
+	Index  int    `json:"index"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest records how a file was split into chunks so resume can tell which blocks are
+// missing and dn can verify the reassembled file, instead of trusting block count alone.
+type Manifest struct {
+	TotalSize int64       `json:"total_size"`
+	ChunkSize int64       `json:"chunk_size"`
+	Chunks    []ChunkInfo `json:"chunks"`
+	// ChunkChainSHA256 is a SHA-256 over the chunks' own SHA256 digests, in index order —
+	// not a hash of the file's raw bytes. Chunks upload concurrently and are hashed as they
+	// stream to Discord, so no single pass ever sees the whole file in byte order; dn must
+	// verify against this same chained digest rather than re-hashing the file directly.
+	ChunkChainSHA256 string `json:"chunk_chain_sha256"`
+}
+
+// UnmarshalJSON decodes a Manifest, accepting the pre-rename "file_sha256" key in place of
+// "chunk_chain_sha256" so manifests pinned before the field was renamed still verify
+// instead of silently decoding to an empty digest.
+func (m *Manifest) UnmarshalJSON(data []byte) error {
+	type alias Manifest
+	aux := &struct {
+		LegacyFileSHA256 string `json:"file_sha256"`
+		*alias
+	}{alias: (*alias)(m)}
+
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+	if m.ChunkChainSHA256 == "" {
+		m.ChunkChainSHA256 = aux.LegacyFileSHA256
+	}
+	return nil
+}
+
+// uploadManifest posts the manifest as a pinned JSON message, marking the channel as
+// manifest-aware for future resumes and downloads. It always runs to completion regardless
+// of whether the chunk upload it's recording succeeded, so it uses its own background
+// context rather than the (possibly already-canceled) context a failed chunkWriter uploaded
+// its chunks under.
+func uploadManifest(session *discordgo.Session, channel *discordgo.Channel, manifest *Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	buildMsg := func() (*discordgo.MessageSend, error) {
+		return createUploadMessage(manifestFilename, bytes.NewReader(data)), nil
+	}
+	message, err := sendMessageWithRetry(context.Background(), session, channel.ID, buildMsg, 10)
+	if err != nil {
+		return fmt.Errorf("failed to upload manifest: %v", err)
+	}
+
+	return session.ChannelMessagePin(channel.ID, message.ID)
+}
+
+// fetchManifest looks for a pinned manifest.json in the channel and returns it. It returns
+// (nil, nil) when the channel predates the manifest format, so callers can fall back to
+// the legacy resume behavior.
+func fetchManifest(session *discordgo.Session, channel *discordgo.Channel) (*Manifest, error) {
+	pins, err := session.ChannelMessagesPinned(channel.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, msg := range pins {
+		for _, att := range msg.Attachments {
+			if att.Filename != manifestFilename {
+				continue
+			}
+
+			resp, err := http.Get(att.URL)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			var manifest Manifest
+			if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+				return nil, err
+			}
+			return &manifest, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// presentChunks lists the channel's data messages (skipping the manifest itself) as a map
+// of block number to attachment size. ChannelMessages pages newest-first, and a resumed
+// upload can leave a stale pre-interruption message behind a freshly re-uploaded one for the
+// same index, so the first (newest) occurrence of an index wins instead of the last.
+func presentChunks(session *discordgo.Session, channel *discordgo.Channel) (map[int]int64, error) {
+	present := make(map[int]int64)
+
+	before := ""
+	for {
+		msgs, err := session.ChannelMessages(channel.ID, 100, before, "", "")
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+
+		for _, msg := range msgs {
+			if len(msg.Attachments) == 0 {
+				continue
+			}
+			att := msg.Attachments[0]
+			if att.Filename == manifestFilename {
+				continue
+			}
+			index, err := strconv.Atoi(att.Filename)
+			if err != nil {
+				continue
+			}
+			if _, ok := present[index]; ok {
+				continue
+			}
+			present[index] = int64(att.Size)
+		}
+
+		before = msgs[len(msgs)-1].ID
+		if len(msgs) < 100 {
+			break
+		}
+	}
+
+	return present, nil
+}
+
+// chunkURLs lists the channel's data messages as a map of block number to attachment URL,
+// for download. As in presentChunks, messages page newest-first, so the first occurrence of
+// an index wins in case a stale duplicate from an interrupted upload is still in the
+// channel.
+func chunkURLs(session *discordgo.Session, channel *discordgo.Channel) (map[int]string, error) {
+	urls := make(map[int]string)
+
+	before := ""
+	for {
+		msgs, err := session.ChannelMessages(channel.ID, 100, before, "", "")
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs) == 0 {
+			break
+		}
+
+		for _, msg := range msgs {
+			if len(msg.Attachments) == 0 {
+				continue
+			}
+			att := msg.Attachments[0]
+			if att.Filename == manifestFilename {
+				continue
+			}
+			index, err := strconv.Atoi(att.Filename)
+			if err != nil {
+				continue
+			}
+			if _, ok := urls[index]; ok {
+				continue
+			}
+			urls[index] = att.URL
+		}
+
+		before = msgs[len(msgs)-1].ID
+		if len(msgs) < 100 {
+			break
+		}
+	}
+
+	return urls, nil
+}
+
+// missingChunks compares the manifest against what the channel actually holds and returns
+// the indices that still need uploading: anything absent, or present with the wrong size.
+func missingChunks(manifest *Manifest, present map[int]int64) []int {
+	var missing []int
+	for _, chunk := range manifest.Chunks {
+		if size, ok := present[chunk.Index]; !ok || size != chunk.Size {
+			missing = append(missing, chunk.Index)
+		}
+	}
+	return missing
+}
+
+// indexChunks builds a lookup of manifest.Chunks by index, so repeated verifyChunk calls
+// over a download don't each rescan the whole manifest.
+func indexChunks(manifest *Manifest) map[int]ChunkInfo {
+	byIndex := make(map[int]ChunkInfo, len(manifest.Chunks))
+	for _, chunk := range manifest.Chunks {
+		byIndex[chunk.Index] = chunk
+	}
+	return byIndex
+}
+
+// verifyChunk checks a downloaded chunk (already decrypted, if the file is encrypted)
+// against the digest its manifest entry recorded at upload time.
+func verifyChunk(chunksByIndex map[int]ChunkInfo, index int, plaintext []byte) error {
+	chunk, ok := chunksByIndex[index]
+	if !ok {
+		return fmt.Errorf("chunk %d not present in manifest", index)
+	}
+
+	sum := sha256.Sum256(plaintext)
+	if got := hex.EncodeToString(sum[:]); got != chunk.SHA256 {
+		return fmt.Errorf("chunk %d failed integrity check: expected sha256 %s, got %s", index, chunk.SHA256, got)
+	}
+	return nil
+}
+
+// inferLegacyProgress replicates dscli's original resume heuristic for channels created
+// before the chunk manifest existed: the first block's attachment size is taken as the
+// chunk size, and the upload is assumed complete up to the highest full-sized block.
+func inferLegacyProgress(session *discordgo.Session, channel *discordgo.Channel, size, maxSize int64) (chunkSize int64, doneBlocks int, err error) {
+	msgs, err := session.ChannelMessages(channel.ID, 1, "", "0", "")
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(msgs) == 0 || len(msgs[0].Attachments) == 0 {
+		return 0, 0, errors.New("cannot infer block size")
+	}
+
+	chunkSize = int64(msgs[0].Attachments[0].Size)
+	if chunkSize > maxSize {
+		return 0, 0, fmt.Errorf(
+			"inferred block size %d is larger than the largest permitted block size %d",
+			chunkSize, maxSize,
+		)
+	}
+
+	msgs, err = session.ChannelMessages(channel.ID, 2, "", "", "")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, msg := range msgs {
+		if len(msg.Attachments) == 0 {
+			continue
+		}
+		if int64(msg.Attachments[0].Size) != chunkSize {
+			return 0, 0, errors.New("complete upload inferred from incomplete last block")
+		}
+		doneBlocks, err = strconv.Atoi(msg.Attachments[0].Filename)
+		if err != nil {
+			return 0, 0, err
+		}
+		break
+	}
+
+	if int64(doneBlocks)*chunkSize == size {
+		return 0, 0, errors.New("upload is already complete")
+	}
+
+	return chunkSize, doneBlocks, nil
+}
@@ -0,0 +1,72 @@
+package discord
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMissingChunks(t *testing.T) {
+	manifest := &Manifest{
+		Chunks: []ChunkInfo{
+			{Index: 1, Size: 10},
+			{Index: 2, Size: 10},
+			{Index: 3, Size: 5},
+		},
+	}
+
+	present := map[int]int64{
+		1: 10, // present and correctly sized
+		2: 7,  // present but wrong size (truncated/corrupt)
+		// 3 absent entirely
+	}
+
+	got := missingChunks(manifest, present)
+	want := []int{2, 3}
+
+	if len(got) != len(want) {
+		t.Fatalf("missingChunks = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("missingChunks = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMissingChunksNoneMissing(t *testing.T) {
+	manifest := &Manifest{
+		Chunks: []ChunkInfo{
+			{Index: 1, Size: 10},
+			{Index: 2, Size: 5},
+		},
+	}
+	present := map[int]int64{1: 10, 2: 5}
+
+	if got := missingChunks(manifest, present); len(got) != 0 {
+		t.Fatalf("missingChunks = %v, want none", got)
+	}
+}
+
+func TestManifestUnmarshalJSONLegacyFileSHA256(t *testing.T) {
+	data := []byte(`{"total_size":10,"chunk_size":10,"chunks":[],"file_sha256":"deadbeef"}`)
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if manifest.ChunkChainSHA256 != "deadbeef" {
+		t.Fatalf("ChunkChainSHA256 = %q, want %q", manifest.ChunkChainSHA256, "deadbeef")
+	}
+}
+
+func TestManifestUnmarshalJSONPrefersCurrentKey(t *testing.T) {
+	data := []byte(`{"total_size":10,"chunk_size":10,"chunks":[],"chunk_chain_sha256":"cafe","file_sha256":"deadbeef"}`)
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if manifest.ChunkChainSHA256 != "cafe" {
+		t.Fatalf("ChunkChainSHA256 = %q, want %q", manifest.ChunkChainSHA256, "cafe")
+	}
+}
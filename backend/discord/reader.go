@@ -0,0 +1,78 @@
+package discord
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// chunkReader implements backend.ChunkReader, downloading and (if the file is encrypted)
+// decrypting each chunk on demand. When the channel has a manifest, every chunk is checked
+// against its recorded digest before it's handed back, so a corrupted or tampered chunk
+// fails dn instead of being silently reassembled into the output file.
+type chunkReader struct {
+	urls          map[int]string
+	numChunks     int
+	aead          cipher.AEAD
+	salt          []byte
+	manifest      *Manifest
+	chunksByIndex map[int]ChunkInfo // built once from manifest.Chunks, for O(1) verifyChunk lookups
+}
+
+func (r *chunkReader) NumChunks() int {
+	return r.numChunks
+}
+
+// ExpectedSHA256 reports the manifest's chunk-chain digest, if the channel has one and the
+// digest was filled in; see Manifest.ChunkChainSHA256 for why it isn't a hash of the file's
+// raw bytes.
+func (r *chunkReader) ExpectedSHA256() (string, bool) {
+	if r.manifest == nil || r.manifest.ChunkChainSHA256 == "" {
+		return "", false
+	}
+	return r.manifest.ChunkChainSHA256, true
+}
+
+func (r *chunkReader) OpenChunk(index int) (io.ReadCloser, error) {
+	url, ok := r.urls[index]
+	if !ok {
+		return nil, fmt.Errorf("chunk %d not found", index)
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.aead == nil && r.manifest == nil {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := body
+	if r.aead != nil {
+		plaintext, err = decryptChunk(r.aead, r.salt, index, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.manifest != nil {
+		if err := verifyChunk(r.chunksByIndex, index, plaintext); err != nil {
+			return nil, err
+		}
+	}
+
+	return io.NopCloser(bytes.NewReader(plaintext)), nil
+}
+
+func (r *chunkReader) Close() error {
+	return nil
+}
@@ -0,0 +1,456 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/time/rate"
+)
+
+// sendMessageWithRetry handles the actual message sending with retry logic. It takes a
+// buildMsg factory rather than a pre-built message because discordgo drains a file's Reader
+// fully into an in-memory multipart body before the network call even happens: reusing the
+// same message on a retry would upload an empty attachment past attempt 1, not actually
+// retry the upload. ctx is checked before each attempt and during the retry backoff, so a
+// worker already mid-retry on one chunk stops promptly once another worker's failure cancels
+// the shared context, instead of sleeping out the rest of its attempts first.
+func sendMessageWithRetry(ctx context.Context, session *discordgo.Session, channelID string, buildMsg func() (*discordgo.MessageSend, error), maxTries int) (*discordgo.Message, error) {
+	var message *discordgo.Message
+	var err error
+
+	for i := 0; i < maxTries; i++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		var msg *discordgo.MessageSend
+		msg, err = buildMsg()
+		if err != nil {
+			return nil, err
+		}
+
+		message, err = session.ChannelMessageSendComplex(channelID, msg)
+		if err == nil {
+			return message, nil
+		}
+
+		if i < maxTries-1 {
+			waitTime := time.Second * time.Duration(i+1)
+			log.Printf("Upload attempt %d failed, retrying in %v: %v", i+1, waitTime, err)
+			select {
+			case <-time.After(waitTime):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("failed after %d attempts: %v", maxTries, err)
+}
+
+// createUploadMessage creates a message with the file attachment.
+func createUploadMessage(name string, data io.Reader) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Files: []*discordgo.File{
+			{
+				Name:   name,
+				Reader: data,
+			},
+		},
+	}
+}
+
+// newUploadLimiter builds a token-bucket limiter from --rate-limit and --burst, or nil if
+// unlimited.
+func newUploadLimiter(bytesPerSec int64, burst int) *rate.Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(bytesPerSec)
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSec), burst)
+}
+
+// countingReader wraps a chunk's source reader so Discord's multipart writer drives the
+// read, instead of the caller buffering the chunk up front: every Read both feeds the rate
+// limiter and accumulates the chunk's running SHA-256, so hashing costs one pass over the
+// bytes rather than a second read-through after the fact.
+type countingReader struct {
+	r       io.Reader
+	hash    hash.Hash
+	ctx     context.Context
+	limiter *rate.Limiter
+	onRead  func(n int)
+	total   int64
+}
+
+func newCountingReader(ctx context.Context, r io.Reader, limiter *rate.Limiter, onRead func(n int)) *countingReader {
+	return &countingReader{r: r, hash: sha256.New(), ctx: ctx, limiter: limiter, onRead: onRead}
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.hash.Write(p[:n])
+		if c.limiter != nil {
+			if werr := waitN(c.ctx, c.limiter, n); werr != nil {
+				return n, werr
+			}
+		}
+		atomic.AddInt64(&c.total, int64(n))
+		c.onRead(n)
+	}
+	return n, err
+}
+
+// waitN reserves n tokens from limiter in Burst()-sized steps. rate.Limiter.WaitN rejects
+// any single call for more than the limiter's burst outright rather than just waiting
+// longer, and callers read in buffers (e.g. discordgo's ~32KB multipart copy) that can
+// easily exceed a deliberately small --burst, so a single un-chunked WaitN(n) can fail a
+// throttled upload on its very first read.
+func waitN(ctx context.Context, limiter *rate.Limiter, n int) error {
+	burst := limiter.Burst()
+	for n > 0 {
+		step := n
+		if step > burst {
+			step = burst
+		}
+		if err := limiter.WaitN(ctx, step); err != nil {
+			return err
+		}
+		n -= step
+	}
+	return nil
+}
+
+func (c *countingReader) Sum() []byte {
+	return c.hash.Sum(nil)
+}
+
+// Len reports how many bytes have been read through so far.
+func (c *countingReader) Len() int64 {
+	return atomic.LoadInt64(&c.total)
+}
+
+// chunkJob is a chunk queued for upload: reader streams straight from the local file and is
+// only read once a worker picks the job up, instead of being buffered ahead of time. It must
+// support Seek so a failed attempt can rewind it and re-read the chunk from the start on
+// retry, rather than resuming from wherever the previous attempt's reader left off.
+type chunkJob struct {
+	index  int
+	size   int64
+	reader io.ReadSeeker
+}
+
+// chunkWriter implements backend.ChunkWriter for a single Discord channel. WriteChunk
+// enqueues each chunk onto a bounded pool of upload workers and returns once the job is
+// queued (blocking while the queue is full) without reading any of the chunk itself, so the caller can keep handing over chunks while earlier
+// ones are still streaming to Discord; Close waits for the pool to drain.
+type chunkWriter struct {
+	b         *Backend
+	channel   *discordgo.Channel
+	filename  string
+	size      int64
+	chunkSize int64
+
+	fresh     bool          // true for CreateFile: post+pin the manifest on Close
+	present   map[int]int64 // manifest-based resume: index -> size already on Discord
+	skipBelow int           // legacy resume: indices <= skipBelow are already uploaded
+
+	aead cipher.AEAD
+	salt []byte
+
+	chunksMu sync.Mutex
+	chunks   []ChunkInfo
+
+	startOnce sync.Once
+	jobs      chan chunkJob
+	wg        sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+	failOnce  sync.Once
+	firstErr  error
+	bar       *progressbar.ProgressBar
+	limiter   *rate.Limiter
+	uploaded  int64
+}
+
+func newChunkWriter(b *Backend, channel *discordgo.Channel, filename string, size, chunkSize int64) *chunkWriter {
+	return &chunkWriter{
+		b:         b,
+		channel:   channel,
+		filename:  filename,
+		size:      size,
+		chunkSize: chunkSize,
+	}
+}
+
+func (w *chunkWriter) ChunkSize() int64 {
+	return w.chunkSize
+}
+
+// sizeOf returns how many plaintext bytes the given 1-based chunk index holds; only the
+// last chunk may be shorter than ChunkSize().
+func (w *chunkWriter) sizeOf(index int) int64 {
+	offset := int64(index-1) * w.chunkSize
+	n := w.chunkSize
+	if offset+n > w.size {
+		n = w.size - offset
+	}
+	return n
+}
+
+// onWireSize returns how many bytes the given 1-based chunk index actually occupies once
+// uploaded: its plaintext size, plus the AEAD tag for encrypted uploads. presentChunks
+// reports the real Discord attachment size, which is ciphertext for an encrypted file, so
+// resume comparisons have to use this instead of the plaintext sizeOf.
+func (w *chunkWriter) onWireSize(index int) int64 {
+	n := w.sizeOf(index)
+	if w.aead != nil {
+		n += int64(w.aead.Overhead())
+	}
+	return n
+}
+
+func (w *chunkWriter) start() {
+	w.startOnce.Do(func() {
+		w.ctx, w.cancel = context.WithCancel(context.Background())
+
+		parallel := w.b.opts.Parallel
+		if parallel < 1 {
+			parallel = 1
+		}
+
+		w.jobs = make(chan chunkJob, parallel)
+		w.limiter = newUploadLimiter(w.b.opts.RateLimit, w.b.opts.Burst)
+
+		if !w.b.opts.Debug {
+			w.bar = progressbar.DefaultBytes(w.size, "Uploading "+w.filename)
+		}
+
+		w.wg.Add(parallel)
+		for i := 0; i < parallel; i++ {
+			go w.worker()
+		}
+	})
+}
+
+// progress reports n newly-read bytes of a chunk to the progress bar / debug log.
+func (w *chunkWriter) progress(n int) {
+	total := atomic.AddInt64(&w.uploaded, int64(n))
+	if w.bar != nil {
+		w.bar.Add(n)
+	} else if w.b.opts.Debug {
+		log.Printf("%d %d", w.size, total)
+	}
+}
+
+func (w *chunkWriter) worker() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case job, ok := <-w.jobs:
+			if !ok {
+				return
+			}
+			if err := w.upload(job); err != nil {
+				w.fail(err)
+				return
+			}
+		}
+	}
+}
+
+// upload streams job's chunk to Discord, hashing it as it goes. Unencrypted chunks stream
+// straight from the local file into Discord's multipart writer with no intermediate buffer;
+// AEAD seals a whole chunk at once, so encrypted chunks still need the plaintext in memory
+// for that one operation. buildMsg re-reads job.reader from the start for every attempt, so
+// a retry after a failed send re-uploads the real chunk instead of whatever's left of a
+// reader discordgo already drained to EOF.
+func (w *chunkWriter) upload(job chunkJob) error {
+	var cr *countingReader
+	var wireSize int64
+
+	buildMsg := func() (*discordgo.MessageSend, error) {
+		if _, err := job.reader.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		limited := io.LimitReader(job.reader, job.size)
+		cr = newCountingReader(w.ctx, limited, w.limiter, w.progress)
+
+		var payload io.Reader
+		if w.aead == nil {
+			payload = cr
+		} else {
+			plaintext, err := io.ReadAll(cr)
+			if err != nil {
+				return nil, err
+			}
+			ciphertext := encryptChunk(w.aead, w.salt, job.index, plaintext)
+			wireSize = int64(len(ciphertext))
+			payload = bytes.NewReader(ciphertext)
+		}
+
+		return createUploadMessage(strconv.Itoa(job.index), payload), nil
+	}
+
+	if _, err := sendMessageWithRetry(w.ctx, w.b.session, w.channel.ID, buildMsg, 10); err != nil {
+		return fmt.Errorf("chunk %d: %v", job.index, err)
+	}
+	chunkSum := cr.Sum()
+	if w.aead == nil {
+		wireSize = cr.Len()
+	}
+
+	if w.fresh {
+		w.chunksMu.Lock()
+		w.chunks = append(w.chunks, ChunkInfo{
+			Index:  job.index,
+			Offset: int64(job.index-1) * w.chunkSize,
+			Size:   wireSize,
+			SHA256: hex.EncodeToString(chunkSum),
+		})
+		w.chunksMu.Unlock()
+	}
+
+	return nil
+}
+
+func (w *chunkWriter) fail(err error) {
+	w.failOnce.Do(func() {
+		w.firstErr = err
+		w.cancel()
+	})
+}
+
+// WriteChunk hands the chunk straight to the upload pool without reading any of it itself:
+// a resumed writer instead decides from the index alone whether the chunk is already on
+// Discord, so even skipped chunks never touch the local file. data must support Seek so a
+// failed upload attempt can retry the chunk from the start; up's io.NewSectionReader per
+// chunk satisfies this.
+func (w *chunkWriter) WriteChunk(index int, data io.Reader) error {
+	w.start()
+
+	skip := false
+	if w.present != nil {
+		if size, ok := w.present[index]; ok && size == w.onWireSize(index) {
+			skip = true
+		}
+	} else if index <= w.skipBelow {
+		skip = true
+	}
+
+	if skip {
+		w.progress(int(w.sizeOf(index)))
+		return nil
+	}
+
+	seeker, ok := data.(io.ReadSeeker)
+	if !ok {
+		return fmt.Errorf("chunk %d: reader must support seeking so a failed upload can retry", index)
+	}
+
+	// Check cancellation before racing the send below: select picks a ready case at random,
+	// so without this a buffered w.jobs slot could still win against an already-done ctx and
+	// accept one more chunk after another worker has failed.
+	select {
+	case <-w.ctx.Done():
+		return w.firstErr
+	default:
+	}
+
+	select {
+	case w.jobs <- chunkJob{index: index, size: w.sizeOf(index), reader: seeker}:
+		return nil
+	case <-w.ctx.Done():
+		return w.firstErr
+	}
+}
+
+// Close drains the upload pool and, for a fresh upload, posts and pins the chunk manifest now
+// that every chunk's outcome is known. It always runs this even after a worker failure
+// (w.firstErr set), posting whatever manifest it can from the chunks that did succeed: a
+// fresh upload that fails partway still needs a manifest on the channel, or a later
+// `up --resume` would find none and fall back to inferLegacyProgress, whose
+// highest-contiguous-block assumption a concurrent, out-of-order upload can violate.
+func (w *chunkWriter) Close() error {
+	if w.jobs != nil {
+		close(w.jobs)
+		w.wg.Wait()
+	}
+
+	if w.fresh {
+		if err := w.postManifest(); err != nil && w.firstErr == nil {
+			return err
+		}
+	}
+
+	return w.firstErr
+}
+
+// postManifest builds a manifest covering every chunk of the file and pins it, using
+// whatever chunks this writer actually finished and a zero-value placeholder for any that
+// didn't — which presentChunks/missingChunks will always see as missing, since no attachment
+// for that index exists on Discord regardless of what the placeholder says. ChunkChainSHA256
+// is only filled in once every chunk made it, since it isn't meaningful for a partial file.
+func (w *chunkWriter) postManifest() error {
+	w.chunksMu.Lock()
+	byIndex := make(map[int]ChunkInfo, len(w.chunks))
+	for _, c := range w.chunks {
+		byIndex[c.Index] = c
+	}
+	w.chunksMu.Unlock()
+
+	totalChunks := int((w.size + w.chunkSize - 1) / w.chunkSize)
+	if totalChunks == 0 {
+		totalChunks = 1
+	}
+
+	chunks := make([]ChunkInfo, totalChunks)
+	complete := true
+	for i := range chunks {
+		index := i + 1
+		if c, ok := byIndex[index]; ok {
+			chunks[i] = c
+		} else {
+			complete = false
+			chunks[i] = ChunkInfo{Index: index, Offset: int64(i) * w.chunkSize}
+		}
+	}
+
+	manifest := &Manifest{
+		TotalSize: w.size,
+		ChunkSize: w.chunkSize,
+		Chunks:    chunks,
+	}
+
+	if complete {
+		fileHash := sha256.New()
+		for _, c := range chunks {
+			sum, err := hex.DecodeString(c.SHA256)
+			if err != nil {
+				return err
+			}
+			fileHash.Write(sum)
+		}
+		manifest.ChunkChainSHA256 = hex.EncodeToString(fileHash.Sum(nil))
+	}
+
+	return uploadManifest(w.b.session, w.channel, manifest)
+}
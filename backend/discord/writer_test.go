@@ -0,0 +1,66 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+func TestNewUploadLimiterUnlimited(t *testing.T) {
+	if l := newUploadLimiter(0, 0); l != nil {
+		t.Fatalf("newUploadLimiter(0, 0) = %v, want nil", l)
+	}
+	if l := newUploadLimiter(-1, 5); l != nil {
+		t.Fatalf("newUploadLimiter(-1, 5) = %v, want nil", l)
+	}
+}
+
+func TestNewUploadLimiterDefaultsBurstToRate(t *testing.T) {
+	l := newUploadLimiter(1000, 0)
+	if l == nil {
+		t.Fatal("newUploadLimiter(1000, 0) = nil, want a limiter")
+	}
+	if got := l.Burst(); got != 1000 {
+		t.Fatalf("Burst() = %d, want 1000", got)
+	}
+}
+
+func TestNewUploadLimiterExplicitBurst(t *testing.T) {
+	l := newUploadLimiter(1000, 50)
+	if l == nil {
+		t.Fatal("newUploadLimiter(1000, 50) = nil, want a limiter")
+	}
+	if got := l.Burst(); got != 50 {
+		t.Fatalf("Burst() = %d, want 50", got)
+	}
+}
+
+// TestCountingReaderReadLargerThanBurst guards against rate.Limiter.WaitN's behavior of
+// rejecting any single call for more than the limiter's burst outright: a --burst smaller
+// than the read buffer discordgo's multipart copy uses (commonly ~32KB) must still succeed,
+// just throttled over more than one WaitN call.
+func TestCountingReaderReadLargerThanBurst(t *testing.T) {
+	limiter := newUploadLimiter(1_000_000_000, 10) // effectively unthrottled rate, tiny burst
+	data := bytes.Repeat([]byte{'a'}, 5000)
+
+	cr := newCountingReader(context.Background(), bytes.NewReader(data), limiter, func(int) {})
+
+	got, err := io.ReadAll(cr)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(got) != len(data) {
+		t.Fatalf("read %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestWaitNRespectsContextCancellation(t *testing.T) {
+	limiter := newUploadLimiter(1, 1) // one byte/sec: any real wait would block the test
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := waitN(ctx, limiter, 5); err == nil {
+		t.Fatal("waitN: expected error for an already-canceled context")
+	}
+}
@@ -0,0 +1,197 @@
+// Package local implements backend.Backend over a plain directory, so dscli's commands can
+// be exercised without hitting Discord.
+package local
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/darenliang/dscli/backend"
+)
+
+func init() {
+	backend.Register("local", New)
+}
+
+// defaultChunkSize is generous enough that most files round-trip in a single chunk; it
+// exists so callers can still exercise their multi-chunk code paths against this backend.
+const defaultChunkSize = 64 << 20 // 64 MiB
+
+// Backend stores files as plain files under dir, named by their dscli filename.
+type Backend struct {
+	dir string
+}
+
+// New constructs a local backend rooted at the directory named by DSCLI_LOCAL_DIR,
+// defaulting to ./dscli-local. Unlike knobs such as rate limiting, --encrypt is rejected
+// rather than silently ignored: a user passing it would otherwise have no indication their
+// files are being written to disk in plaintext.
+func New(opts backend.Options) (backend.Backend, error) {
+	if opts.Encrypt {
+		return nil, errors.New("the local backend does not support --encrypt")
+	}
+
+	dir := os.Getenv("DSCLI_LOCAL_DIR")
+	if dir == "" {
+		dir = "dscli-local"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Backend{dir: dir}, nil
+}
+
+func (b *Backend) path(name string) string {
+	return filepath.Join(b.dir, name)
+}
+
+func (b *Backend) CreateFile(name string, size int64) (backend.ChunkWriter, error) {
+	if _, err := os.Stat(b.path(name)); err == nil {
+		return nil, fmt.Errorf("%s already exists", name)
+	}
+
+	file, err := os.Create(b.path(name))
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &writer{file: file}, nil
+}
+
+func (b *Backend) OpenWriter(name string, size int64) (backend.ChunkWriter, error) {
+	file, err := os.OpenFile(b.path(name), os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("%s does not exist: %v", name, err)
+	}
+	return &writer{file: file}, nil
+}
+
+func (b *Backend) OpenReader(name string) (backend.ChunkReader, error) {
+	file, err := os.Open(b.path(name))
+	if err != nil {
+		return nil, fmt.Errorf("%s not found: %v", name, err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	chunks := int((stat.Size() + defaultChunkSize - 1) / defaultChunkSize)
+	if chunks == 0 {
+		chunks = 1
+	}
+
+	return &reader{file: file, size: stat.Size(), chunks: chunks}, nil
+}
+
+func (b *Backend) Delete(name string) error {
+	if err := os.Remove(b.path(name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s not found", name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *Backend) List() ([]backend.FileInfo, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]backend.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, backend.FileInfo{Name: entry.Name(), Size: info.Size()})
+	}
+	return files, nil
+}
+
+func (b *Backend) Close() error {
+	return nil
+}
+
+// writer writes each chunk at its offset in the file, so chunks can arrive in any order.
+type writer struct {
+	file *os.File
+}
+
+func (w *writer) ChunkSize() int64 {
+	return defaultChunkSize
+}
+
+// WriteChunk streams data straight into the file at the chunk's offset via io.Copy, rather
+// than buffering the whole chunk in memory first.
+func (w *writer) WriteChunk(index int, data io.Reader) error {
+	dst := &offsetWriter{file: w.file, offset: int64(index-1) * defaultChunkSize}
+	_, err := io.Copy(dst, data)
+	return err
+}
+
+// offsetWriter adapts os.File.WriteAt to io.Writer, advancing its own offset after each
+// write so io.Copy can stream into an arbitrary position in the file.
+type offsetWriter struct {
+	file   *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.file.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+func (w *writer) Close() error {
+	return w.file.Close()
+}
+
+// reader serves fixed-size chunks back out of the file via ReadAt.
+type reader struct {
+	file   *os.File
+	size   int64
+	chunks int
+}
+
+func (r *reader) NumChunks() int {
+	return r.chunks
+}
+
+// ExpectedSHA256 always reports no digest: the local backend keeps no manifest to check
+// downloads against.
+func (r *reader) ExpectedSHA256() (string, bool) {
+	return "", false
+}
+
+func (r *reader) OpenChunk(index int) (io.ReadCloser, error) {
+	if index < 1 || index > r.chunks {
+		return nil, errors.New("chunk index out of range")
+	}
+
+	offset := int64(index-1) * defaultChunkSize
+	n := int64(defaultChunkSize)
+	if offset+n > r.size {
+		n = r.size - offset
+	}
+
+	return io.NopCloser(io.NewSectionReader(r.file, offset, n)), nil
+}
+
+func (r *reader) Close() error {
+	return r.file.Close()
+}
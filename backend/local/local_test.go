@@ -0,0 +1,135 @@
+package local
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/darenliang/dscli/backend"
+)
+
+func newTestBackend(t *testing.T) backend.Backend {
+	t.Helper()
+	t.Setenv("DSCLI_LOCAL_DIR", t.TempDir())
+
+	be, err := New(backend.Options{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { be.Close() })
+	return be
+}
+
+func writeFile(t *testing.T, be backend.Backend, name string, data []byte) {
+	t.Helper()
+
+	w, err := be.CreateFile(name, int64(len(data)))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	if err := w.WriteChunk(1, bytes.NewReader(data)); err != nil {
+		t.Fatalf("WriteChunk: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func readFile(t *testing.T, be backend.Backend, name string) []byte {
+	t.Helper()
+
+	r, err := be.OpenReader(name)
+	if err != nil {
+		t.Fatalf("OpenReader: %v", err)
+	}
+	defer r.Close()
+
+	var out []byte
+	for i := 1; i <= r.NumChunks(); i++ {
+		chunk, err := r.OpenChunk(i)
+		if err != nil {
+			t.Fatalf("OpenChunk(%d): %v", i, err)
+		}
+		data, err := io.ReadAll(chunk)
+		chunk.Close()
+		if err != nil {
+			t.Fatalf("read chunk %d: %v", i, err)
+		}
+		out = append(out, data...)
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	be := newTestBackend(t)
+	want := []byte("hello from dscli's local backend")
+
+	writeFile(t, be, "greeting.txt", want)
+
+	if got := readFile(t, be, "greeting.txt"); !bytes.Equal(got, want) {
+		t.Fatalf("read back %q, want %q", got, want)
+	}
+
+	r, err := be.OpenReader("greeting.txt")
+	if err != nil {
+		t.Fatalf("OpenReader: %v", err)
+	}
+	defer r.Close()
+	if _, ok := r.ExpectedSHA256(); ok {
+		t.Fatal("ExpectedSHA256: expected ok=false for the local backend")
+	}
+}
+
+func TestCreateFileAlreadyExists(t *testing.T) {
+	be := newTestBackend(t)
+	writeFile(t, be, "dup.txt", []byte("data"))
+
+	if _, err := be.CreateFile("dup.txt", 4); err == nil {
+		t.Fatal("CreateFile: expected error for an existing file")
+	}
+}
+
+func TestNewRejectsEncrypt(t *testing.T) {
+	t.Setenv("DSCLI_LOCAL_DIR", t.TempDir())
+
+	if _, err := New(backend.Options{Encrypt: true}); err == nil {
+		t.Fatal("New: expected error for Options.Encrypt")
+	}
+}
+
+func TestOpenReaderNotFound(t *testing.T) {
+	be := newTestBackend(t)
+
+	if _, err := be.OpenReader("missing.txt"); err == nil {
+		t.Fatal("OpenReader: expected error for a missing file")
+	}
+}
+
+func TestDeleteAndList(t *testing.T) {
+	be := newTestBackend(t)
+	writeFile(t, be, "a.txt", []byte("a"))
+	writeFile(t, be, "b.txt", []byte("bb"))
+
+	files, err := be.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("List returned %d files, want 2", len(files))
+	}
+
+	if err := be.Delete("a.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := be.Delete("a.txt"); err == nil {
+		t.Fatal("Delete: expected error deleting an already-removed file")
+	}
+
+	files, err = be.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "b.txt" {
+		t.Fatalf("List after delete = %v, want only b.txt", files)
+	}
+}
@@ -0,0 +1,8 @@
+package cmd
+
+// Blank-imported so their init() registers with the backend registry; see
+// github.com/darenliang/dscli/backend for the Backend interface these implement.
+import (
+	_ "github.com/darenliang/dscli/backend/discord"
+	_ "github.com/darenliang/dscli/backend/local"
+)
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/darenliang/dscli/backend"
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dnDebug    bool
+	dnPassword string
+	dnBackend  string
+)
+
+// dnCmd represents the dn command
+var dnCmd = &cobra.Command{
+	Use:        "dn <remote file> [local file]",
+	Example:    "dn test.txt test.txt",
+	SuggestFor: []string{"download"},
+	Short:      "Download file",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("requires at least one argument")
+		}
+		return nil
+	},
+	RunE: dn,
+}
+
+func init() {
+	dnCmd.Flags().BoolVarP(&dnDebug, "debug", "d", false, "debug mode: <chunk index> <bytes written>")
+	dnCmd.Flags().StringVar(&dnPassword, "password", "", "passphrase to derive the decryption key from (falls back to DSCLI_PASSWORD)")
+	dnCmd.Flags().StringVar(&dnBackend, "backend", "discord", "storage backend to download from (discord, local)")
+
+	rootCmd.AddCommand(dnCmd)
+}
+
+// dn command handler
+func dn(cmd *cobra.Command, args []string) error {
+	remote := args[0] // remote filename
+
+	var local string
+	if len(args) == 1 {
+		_, local = filepath.Split(remote)
+	} else {
+		local = args[1]
+	}
+
+	be, err := backend.New(dnBackend, backend.Options{
+		Password: dnPassword,
+		Debug:    dnDebug,
+	})
+	if err != nil {
+		return err
+	}
+	defer be.Close()
+
+	reader, err := be.OpenReader(remote)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// Download into a temp file and only replace local once every chunk is in and
+	// verified, so a failed or interrupted download can't destroy a good existing file.
+	dir, base := filepath.Split(local)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := os.CreateTemp(dir, base+".dscli-tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := file.Name()
+	defer os.Remove(tmpName) // no-op once the rename below succeeds
+
+	if err := downloadChunks(reader, file, dnDebug); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, local)
+}
+
+// downloadChunks reads every chunk from reader in order and writes it to file, hashing each
+// chunk and chaining those digests together as it goes, so the whole download can be
+// checked against the backend's expected digest once every chunk is in. This mirrors how
+// the chunk manifest's own digest is built on upload: chunks there are hashed as they
+// stream to Discord, never in one pass over the whole file, so ExpectedSHA256 is a
+// chain-of-chunk-digests hash rather than a hash of the file's raw bytes.
+func downloadChunks(reader backend.ChunkReader, file *os.File, debug bool) error {
+	chainHash := sha256.New()
+
+	var bar *progressbar.ProgressBar
+	if !debug {
+		bar = progressbar.DefaultBytes(-1, "Downloading")
+	}
+
+	var written int64
+	for index := 1; index <= reader.NumChunks(); index++ {
+		chunk, err := reader.OpenChunk(index)
+		if err != nil {
+			return fmt.Errorf("chunk %d: %v", index, err)
+		}
+
+		chunkHash := sha256.New()
+		n, err := io.Copy(io.MultiWriter(file, chunkHash), chunk)
+		chunk.Close()
+		if err != nil {
+			return fmt.Errorf("chunk %d: %v", index, err)
+		}
+		chainHash.Write(chunkHash.Sum(nil))
+
+		written += n
+		if bar != nil {
+			bar.Add64(n)
+		} else {
+			log.Printf("%d %d", index, written)
+		}
+	}
+
+	if expected, ok := reader.ExpectedSHA256(); ok {
+		if got := hex.EncodeToString(chainHash.Sum(nil)); got != expected {
+			return fmt.Errorf("downloaded file failed integrity check: expected %s, got %s", expected, got)
+		}
+	}
+
+	return nil
+}
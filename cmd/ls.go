@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/darenliang/dscli/backend"
+	"github.com/spf13/cobra"
+)
+
+var lsBackend string
+
+// lsCmd represents the ls command
+var lsCmd = &cobra.Command{
+	Use:        "ls",
+	Example:    "ls",
+	SuggestFor: []string{"list"},
+	Short:      "List files",
+	RunE:       ls,
+}
+
+func init() {
+	lsCmd.Flags().StringVar(&lsBackend, "backend", "discord", "storage backend to list (discord, local)")
+	rootCmd.AddCommand(lsCmd)
+}
+
+// ls command handler
+func ls(cmd *cobra.Command, args []string) error {
+	be, err := backend.New(lsBackend, backend.Options{})
+	if err != nil {
+		return err
+	}
+	defer be.Close()
+
+	files, err := be.List()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		fmt.Printf("%s\t%d\n", file.Name, file.Size)
+	}
+	return nil
+}
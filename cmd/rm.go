@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"errors"
-	"github.com/darenliang/dscli/common"
+
+	"github.com/darenliang/dscli/backend"
 	"github.com/spf13/cobra"
 )
 
+var rmBackend string
+
 // rmCmd represents the rm command
 var rmCmd = &cobra.Command{
 	Use:        "rm file",
@@ -22,6 +25,7 @@ var rmCmd = &cobra.Command{
 }
 
 func init() {
+	rmCmd.Flags().StringVar(&rmBackend, "backend", "discord", "storage backend to remove from (discord, local)")
 	rootCmd.AddCommand(rmCmd)
 }
 
@@ -29,26 +33,11 @@ func init() {
 func rm(cmd *cobra.Command, args []string) error {
 	filename := args[0]
 
-	session, _, channels, err := common.GetDiscordSession()
+	be, err := backend.New(rmBackend, backend.Options{})
 	if err != nil {
 		return err
 	}
-	defer session.Close()
+	defer be.Close()
 
-	fileMap, err := common.ParseFileMap(channels)
-	if err != nil {
-		return err
-	}
-
-	// old file exists
-	if channel, ok := fileMap[filename]; ok {
-		// remove file (via channel delete)
-		_, err = session.ChannelDelete(channel.ID)
-		if err != nil {
-			return errors.New("cannot delete file: " + err.Error())
-		}
-		return nil
-	} else {
-		return errors.New(filename + " not found")
-	}
+	return be.Delete(filename)
 }